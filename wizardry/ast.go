@@ -2,6 +2,8 @@ package wizardry
 
 import (
 	"encoding/binary"
+	"fmt"
+	"math"
 )
 
 // Spellbook contains a set of rules - at least one "" page, potentially others
@@ -72,6 +74,36 @@ type IntegerKind struct {
 	MatchAny    bool
 }
 
+// Matches applies DoAnd's mask (if set) to value, then compares it against Value per
+// IntegerTest
+func (ik IntegerKind) Matches(value int64) bool {
+	if ik.MatchAny {
+		return true
+	}
+	v := value
+	if ik.DoAnd {
+		v = int64(uint64(v) & ik.AndValue)
+	}
+	switch ik.IntegerTest {
+	case IntegerTestEqual:
+		return v == ik.Value
+	case IntegerTestNotEqual:
+		return v != ik.Value
+	case IntegerTestLessThan:
+		return v < ik.Value
+	case IntegerTestGreaterThan:
+		return v > ik.Value
+	case IntegerTestBitAnd:
+		return uint64(v)&uint64(ik.Value) == uint64(ik.Value)
+	case IntegerTestBitClear:
+		return uint64(v)&uint64(ik.Value) == 0
+	case IntegerTestBitComplement:
+		return v == ^ik.Value
+	default:
+		return false
+	}
+}
+
 // IntegerTest describes which comparison to perform on an integer
 type IntegerTest int
 
@@ -84,6 +116,75 @@ const (
 	IntegerTestLessThan = iota
 	// IntegerTestGreaterThan tests that one integer is greater than the other
 	IntegerTestGreaterThan = iota
+	// IntegerTestBitAnd tests that all bits set in Value are also set in the target (x & Value == Value)
+	IntegerTestBitAnd = iota
+	// IntegerTestBitClear tests that none of the bits set in Value are set in the target (x & Value == 0)
+	IntegerTestBitClear = iota
+	// IntegerTestBitComplement tests the target against the one's complement of Value (x == ^Value)
+	IntegerTestBitComplement = iota
+)
+
+// FloatKind describes how to perform a test on an IEEE 754 floating-point number
+type FloatKind struct {
+	ByteWidth  int
+	Endianness Endianness
+	FloatTest  FloatTest
+	Value      float64
+	Epsilon    float64
+	MatchAny   bool
+}
+
+// Decode reads the floating-point number out of raw, using the ByteWidth and
+// Endianness of the FloatKind (4 bytes for float32, 8 bytes for float64)
+func (fk FloatKind) Decode(raw []byte) (float64, error) {
+	if len(raw) < fk.ByteWidth {
+		return 0, fmt.Errorf("wizardry: float kind needs %d bytes, got %d", fk.ByteWidth, len(raw))
+	}
+	bo := fk.Endianness.ByteOrder()
+	if fk.ByteWidth == 4 {
+		return float64(math.Float32frombits(bo.Uint32(raw))), nil
+	}
+	return math.Float64frombits(bo.Uint64(raw)), nil
+}
+
+// Matches decodes raw and compares it against Value per FloatTest, treating the two as
+// equal whenever they're within Epsilon of each other rather than requiring exact
+// equality, since IEEE 754 values rarely compare equal after a round-trip through a file
+func (fk FloatKind) Matches(raw []byte) (bool, error) {
+	if fk.MatchAny {
+		return true, nil
+	}
+	decoded, err := fk.Decode(raw)
+	if err != nil {
+		return false, err
+	}
+	diff := decoded - fk.Value
+	switch fk.FloatTest {
+	case FloatTestEqual:
+		return math.Abs(diff) <= fk.Epsilon, nil
+	case FloatTestNotEqual:
+		return math.Abs(diff) > fk.Epsilon, nil
+	case FloatTestLessThan:
+		return decoded < fk.Value, nil
+	case FloatTestGreaterThan:
+		return decoded > fk.Value, nil
+	default:
+		return false, fmt.Errorf("wizardry: unknown FloatTest %d", fk.FloatTest)
+	}
+}
+
+// FloatTest describes which comparison to perform on a floating-point number
+type FloatTest int
+
+const (
+	// FloatTestEqual tests that two floats are equal, within Epsilon
+	FloatTestEqual FloatTest = iota
+	// FloatTestNotEqual tests that two floats are not equal, within Epsilon
+	FloatTestNotEqual = iota
+	// FloatTestLessThan tests that one float is less than the other
+	FloatTestLessThan = iota
+	// FloatTestGreaterThan tests that one float is greater than the other
+	FloatTestGreaterThan = iota
 )
 
 // StringKind describes how to match a string pattern
@@ -113,6 +214,14 @@ const (
 	KindFamilyDefault = iota
 	// KindFamilyClear resets the matched flag for that level
 	KindFamilyClear = iota
+	// KindFamilyFloat tests IEEE 754 floating-point numbers for equality, inequality, etc.
+	KindFamilyFloat = iota
+	// KindFamilyRegex looks for a regular expression match within a bounded window
+	KindFamilyRegex = iota
+	// KindFamilyDate tests a date/time value, rendering it with a Go time layout
+	KindFamilyDate = iota
+	// KindFamilyUse jumps to another page's rules at the current offset, as a subroutine
+	KindFamilyUse = iota
 )
 
 // Offset describes where to look to compare something
@@ -144,6 +253,40 @@ type IndirectOffset struct {
 	OffsetAdjustmentValue      int64
 }
 
+// Adjust applies OffsetAdjustmentType to base using OffsetAdjustmentValue, implementing
+// the postfix arithmetic indirect offsets support, e.g. (( .l & 0xffff ) + 4). Division
+// and modulo by zero are no-ops rather than panics, since a malformed rule shouldn't be
+// able to crash the reader.
+func (io IndirectOffset) Adjust(base int64) int64 {
+	value := io.OffsetAdjustmentValue
+	switch io.OffsetAdjustmentType {
+	case OffsetAdjustmentAdd:
+		return base + value
+	case OffsetAdjustmentSub:
+		return base - value
+	case OffsetAdjustmentMul:
+		return base * value
+	case OffsetAdjustmentDiv:
+		if value == 0 {
+			return base
+		}
+		return base / value
+	case OffsetAdjustmentMod:
+		if value == 0 {
+			return base
+		}
+		return base % value
+	case OffsetAdjustmentAnd:
+		return base & value
+	case OffsetAdjustmentOr:
+		return base | value
+	case OffsetAdjustmentXor:
+		return base ^ value
+	default:
+		return base
+	}
+}
+
 // OffsetAdjustment describes which operation to apply to an offset
 type OffsetAdjustment int
 
@@ -158,4 +301,12 @@ const (
 	OffsetAdjustmentMul = iota
 	// OffsetAdjustmentDiv divides by a value
 	OffsetAdjustmentDiv = iota
+	// OffsetAdjustmentMod takes the remainder of a division by a value
+	OffsetAdjustmentMod = iota
+	// OffsetAdjustmentAnd applies a bitwise AND with a value
+	OffsetAdjustmentAnd = iota
+	// OffsetAdjustmentOr applies a bitwise OR with a value
+	OffsetAdjustmentOr = iota
+	// OffsetAdjustmentXor applies a bitwise XOR with a value
+	OffsetAdjustmentXor = iota
 )
\ No newline at end of file
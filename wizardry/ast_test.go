@@ -0,0 +1,94 @@
+package wizardry
+
+import "testing"
+
+func TestIntegerKindMatchesBitwise(t *testing.T) {
+	tests := []struct {
+		name string
+		ik   IntegerKind
+		val  int64
+		want bool
+	}{
+		{
+			name: "bit and - all bits set",
+			ik:   IntegerKind{IntegerTest: IntegerTestBitAnd, Value: 0x06},
+			val:  0x0e,
+			want: true,
+		},
+		{
+			name: "bit and - missing a bit",
+			ik:   IntegerKind{IntegerTest: IntegerTestBitAnd, Value: 0x06},
+			val:  0x04,
+			want: false,
+		},
+		{
+			name: "bit clear - none set",
+			ik:   IntegerKind{IntegerTest: IntegerTestBitClear, Value: 0x06},
+			val:  0x01,
+			want: true,
+		},
+		{
+			name: "bit clear - one set",
+			ik:   IntegerKind{IntegerTest: IntegerTestBitClear, Value: 0x06},
+			val:  0x02,
+			want: false,
+		},
+		{
+			name: "bit complement - matches one's complement",
+			ik:   IntegerKind{IntegerTest: IntegerTestBitComplement, Value: 0x0f},
+			val:  ^int64(0x0f),
+			want: true,
+		},
+		{
+			name: "doAnd masks the value before the bit test",
+			ik:   IntegerKind{IntegerTest: IntegerTestBitAnd, Value: 0x01, DoAnd: true, AndValue: 0x01},
+			val:  0xff,
+			want: true,
+		},
+		{
+			name: "matchAny short-circuits everything",
+			ik:   IntegerKind{IntegerTest: IntegerTestBitClear, Value: 0xff, MatchAny: true},
+			val:  0xff,
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ik.Matches(tt.val); got != tt.want {
+				t.Errorf("Matches(%#x) = %v, want %v", tt.val, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndirectOffsetAdjust(t *testing.T) {
+	tests := []struct {
+		name string
+		adj  OffsetAdjustment
+		base int64
+		val  int64
+		want int64
+	}{
+		{"none", OffsetAdjustmentNone, 10, 4, 10},
+		{"add", OffsetAdjustmentAdd, 10, 4, 14},
+		{"sub", OffsetAdjustmentSub, 10, 4, 6},
+		{"mul", OffsetAdjustmentMul, 10, 4, 40},
+		{"div", OffsetAdjustmentDiv, 10, 4, 2},
+		{"div by zero is a no-op", OffsetAdjustmentDiv, 10, 0, 10},
+		{"mod", OffsetAdjustmentMod, 10, 4, 2},
+		{"mod by zero is a no-op", OffsetAdjustmentMod, 10, 0, 10},
+		{"and", OffsetAdjustmentAnd, 0xff, 0x0f, 0x0f},
+		{"or", OffsetAdjustmentOr, 0xf0, 0x0f, 0xff},
+		{"xor", OffsetAdjustmentXor, 0xff, 0x0f, 0xf0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			io := IndirectOffset{OffsetAdjustmentType: tt.adj, OffsetAdjustmentValue: tt.val}
+			if got := io.Adjust(tt.base); got != tt.want {
+				t.Errorf("Adjust(%d) = %d, want %d", tt.base, got, tt.want)
+			}
+		})
+	}
+}
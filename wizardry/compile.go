@@ -0,0 +1,676 @@
+package wizardry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// compiledMagic is the magic header every compiled Spellbook starts with, so a stray
+// file (or one compiled by a newer/older wizardry) is rejected instead of misparsed
+var compiledMagic = [4]byte{'W', 'Z', 'M', 'C'}
+
+// compiledFormatVersion is bumped any time the binary layout below changes incompatibly
+const compiledFormatVersion uint16 = 1
+
+// compiledByteOrder is the byte order the compiled format itself is written in,
+// independent of the Endianness values stored as data inside Kind/IndirectOffset
+var compiledByteOrder = binary.LittleEndian
+
+// compiledEndiannessMarker records which byte order compiledByteOrder is, purely so a
+// reader can sanity-check the file instead of silently misinterpreting it
+const compiledEndiannessMarker byte = 0 // 0 = little-endian, 1 = big-endian
+
+// LoadCompiled reads a Spellbook previously written by SaveCompiled
+func LoadCompiled(path string) (Spellbook, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sb := Spellbook{}
+	if err := sb.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	return sb, nil
+}
+
+// SaveCompiled writes sb to path in the binary format produced by MarshalBinary
+func (sb Spellbook) SaveCompiled(path string) error {
+	raw, err := sb.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, raw, 0644)
+}
+
+// MarshalBinary serializes sb - every page, Rule, Kind variant, Offset and
+// IndirectOffset - into a compact versioned binary format. Strings that tend to
+// repeat across rules (page names, descriptions) are stored once in an intern
+// table and referenced by index elsewhere, mirroring what file(1) does for .mgc.
+//
+// Pages are visited in sorted order, both while building the intern table and
+// while writing, rather than via Go's randomized map iteration - so the same
+// Spellbook always marshals to the same bytes, which callers rely on to detect
+// a stale compiled cache by comparing output rather than recompiling every time.
+func (sb Spellbook) MarshalBinary() ([]byte, error) {
+	pages := make([]string, 0, len(sb))
+	for page := range sb {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+
+	interner := newStringInterner()
+	for _, page := range pages {
+		interner.intern(page)
+		for _, rule := range sb[page] {
+			internRuleStrings(interner, rule)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(compiledMagic[:])
+	binary.Write(&buf, compiledByteOrder, compiledFormatVersion)
+	buf.WriteByte(compiledEndiannessMarker)
+
+	interner.writeTo(&buf)
+
+	binary.Write(&buf, compiledByteOrder, uint32(len(pages)))
+	for _, page := range pages {
+		writeUint32(&buf, interner.indexOf(page))
+		rules := sb[page]
+		binary.Write(&buf, compiledByteOrder, uint32(len(rules)))
+		for _, rule := range rules {
+			if err := writeRule(&buf, interner, rule); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces sb's contents with the Spellbook encoded in raw,
+// rejecting it outright if the header or format version doesn't match
+func (sb Spellbook) UnmarshalBinary(raw []byte) error {
+	r := bufio.NewReader(bytes.NewReader(raw))
+
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return fmt.Errorf("wizardry: truncated compiled header: %w", err)
+	}
+	if header != compiledMagic {
+		return fmt.Errorf("wizardry: not a compiled spellbook (bad magic %q)", header)
+	}
+
+	var version uint16
+	if err := binary.Read(r, compiledByteOrder, &version); err != nil {
+		return err
+	}
+	if version != compiledFormatVersion {
+		return fmt.Errorf("wizardry: compiled spellbook has format version %d, want %d", version, compiledFormatVersion)
+	}
+	marker, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if marker != compiledEndiannessMarker {
+		return fmt.Errorf("wizardry: compiled spellbook has endianness marker %d, want %d", marker, compiledEndiannessMarker)
+	}
+
+	interner, err := readStringInterner(r)
+	if err != nil {
+		return err
+	}
+
+	var pageCount uint32
+	if err := binary.Read(r, compiledByteOrder, &pageCount); err != nil {
+		return err
+	}
+	for page := range sb {
+		delete(sb, page)
+	}
+	for i := uint32(0); i < pageCount; i++ {
+		pageIdx, err := readUint32(r)
+		if err != nil {
+			return err
+		}
+		page, err := interner.at(pageIdx)
+		if err != nil {
+			return err
+		}
+		var ruleCount uint32
+		if err := binary.Read(r, compiledByteOrder, &ruleCount); err != nil {
+			return err
+		}
+		rules := make([]Rule, 0, ruleCount)
+		for j := uint32(0); j < ruleCount; j++ {
+			rule, err := readRule(r, interner)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		sb[page] = rules
+	}
+	return nil
+}
+
+// stringInterner deduplicates strings seen while marshaling a Spellbook, so a
+// description or page name repeated across hundreds of rules is stored once
+type stringInterner struct {
+	values  []string
+	indices map[string]uint32
+}
+
+func newStringInterner() *stringInterner {
+	return &stringInterner{indices: map[string]uint32{}}
+}
+
+func (si *stringInterner) intern(s string) uint32 {
+	if idx, ok := si.indices[s]; ok {
+		return idx
+	}
+	idx := uint32(len(si.values))
+	si.values = append(si.values, s)
+	si.indices[s] = idx
+	return idx
+}
+
+func (si *stringInterner) indexOf(s string) uint32 {
+	return si.indices[s]
+}
+
+func (si *stringInterner) at(idx uint32) (string, error) {
+	if idx >= uint32(len(si.values)) {
+		return "", fmt.Errorf("wizardry: string intern index %d out of range (table has %d entries)", idx, len(si.values))
+	}
+	return si.values[idx], nil
+}
+
+func (si *stringInterner) writeTo(buf *bytes.Buffer) {
+	binary.Write(buf, compiledByteOrder, uint32(len(si.values)))
+	for _, s := range si.values {
+		writeBytes(buf, []byte(s))
+	}
+}
+
+func readStringInterner(r io.Reader) (*stringInterner, error) {
+	var count uint32
+	if err := binary.Read(r, compiledByteOrder, &count); err != nil {
+		return nil, err
+	}
+	si := newStringInterner()
+	for i := uint32(0); i < count; i++ {
+		b, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		si.intern(string(b))
+	}
+	return si, nil
+}
+
+// internRuleStrings walks rule (and its Kind) looking for strings worth interning
+func internRuleStrings(interner *stringInterner, rule Rule) {
+	interner.intern(string(rule.Description))
+	switch data := rule.Kind.Data.(type) {
+	case StringKind:
+		interner.intern(string(data.Value))
+	case SearchKind:
+		interner.intern(string(data.Value))
+	case *RegexKind:
+		interner.intern(data.Pattern)
+	case DateKind:
+		interner.intern(data.Layout)
+	case UseKind:
+		interner.intern(data.Page)
+	}
+}
+
+func writeRule(buf *bytes.Buffer, interner *stringInterner, rule Rule) error {
+	binary.Write(buf, compiledByteOrder, int64(rule.Level))
+	writeOffset(buf, rule.Offset)
+	writeUint32(buf, interner.indexOf(string(rule.Description)))
+	return writeKind(buf, interner, rule.Kind)
+}
+
+func readRule(r *bufio.Reader, interner *stringInterner) (Rule, error) {
+	var rule Rule
+	var level int64
+	if err := binary.Read(r, compiledByteOrder, &level); err != nil {
+		return rule, err
+	}
+	rule.Level = int(level)
+
+	offset, err := readOffset(r)
+	if err != nil {
+		return rule, err
+	}
+	rule.Offset = offset
+
+	descIdx, err := readUint32(r)
+	if err != nil {
+		return rule, err
+	}
+	desc, err := interner.at(descIdx)
+	if err != nil {
+		return rule, err
+	}
+	rule.Description = []byte(desc)
+
+	kind, err := readKind(r, interner)
+	if err != nil {
+		return rule, err
+	}
+	rule.Kind = kind
+	return rule, nil
+}
+
+func writeOffset(buf *bytes.Buffer, off Offset) {
+	binary.Write(buf, compiledByteOrder, int32(off.OffsetType))
+	writeBool(buf, off.IsRelative)
+	binary.Write(buf, compiledByteOrder, off.Direct)
+	writeBool(buf, off.Indirect != nil)
+	if off.Indirect != nil {
+		writeIndirectOffset(buf, *off.Indirect)
+	}
+}
+
+func readOffset(r io.Reader) (Offset, error) {
+	var off Offset
+	offsetType, err := readInt32(r)
+	if err != nil {
+		return off, err
+	}
+	off.OffsetType = OffsetType(offsetType)
+
+	if off.IsRelative, err = readBool(r); err != nil {
+		return off, err
+	}
+
+	if off.Direct, err = readInt64(r); err != nil {
+		return off, err
+	}
+
+	hasIndirect, err := readBool(r)
+	if err != nil {
+		return off, err
+	}
+	if hasIndirect {
+		indirect, err := readIndirectOffset(r)
+		if err != nil {
+			return off, err
+		}
+		off.Indirect = &indirect
+	}
+	return off, nil
+}
+
+func writeIndirectOffset(buf *bytes.Buffer, io IndirectOffset) {
+	writeBool(buf, io.IsRelative)
+	binary.Write(buf, compiledByteOrder, int32(io.ByteWidth))
+	binary.Write(buf, compiledByteOrder, int32(io.Endianness))
+	binary.Write(buf, compiledByteOrder, io.OffsetAddress)
+	binary.Write(buf, compiledByteOrder, int32(io.OffsetAdjustmentType))
+	writeBool(buf, io.OffsetAdjustmentIsRelative)
+	binary.Write(buf, compiledByteOrder, io.OffsetAdjustmentValue)
+}
+
+func readIndirectOffset(r io.Reader) (IndirectOffset, error) {
+	var iOff IndirectOffset
+	var err error
+	if iOff.IsRelative, err = readBool(r); err != nil {
+		return iOff, err
+	}
+	byteWidth, err := readInt32(r)
+	if err != nil {
+		return iOff, err
+	}
+	iOff.ByteWidth = int(byteWidth)
+
+	endianness, err := readInt32(r)
+	if err != nil {
+		return iOff, err
+	}
+	iOff.Endianness = Endianness(endianness)
+
+	if iOff.OffsetAddress, err = readInt64(r); err != nil {
+		return iOff, err
+	}
+
+	adjustmentType, err := readInt32(r)
+	if err != nil {
+		return iOff, err
+	}
+	iOff.OffsetAdjustmentType = OffsetAdjustment(adjustmentType)
+
+	if iOff.OffsetAdjustmentIsRelative, err = readBool(r); err != nil {
+		return iOff, err
+	}
+	if iOff.OffsetAdjustmentValue, err = readInt64(r); err != nil {
+		return iOff, err
+	}
+	return iOff, nil
+}
+
+// writeKind writes the Family discriminator followed by the family-specific payload
+func writeKind(buf *bytes.Buffer, interner *stringInterner, kind Kind) error {
+	binary.Write(buf, compiledByteOrder, int32(kind.Family))
+	switch kind.Family {
+	case KindFamilyInteger:
+		data := kind.Data.(IntegerKind)
+		binary.Write(buf, compiledByteOrder, int32(data.ByteWidth))
+		binary.Write(buf, compiledByteOrder, int32(data.Endianness))
+		writeBool(buf, data.Signed)
+		writeBool(buf, data.DoAnd)
+		binary.Write(buf, compiledByteOrder, data.AndValue)
+		binary.Write(buf, compiledByteOrder, int32(data.IntegerTest))
+		binary.Write(buf, compiledByteOrder, data.Value)
+		writeBool(buf, data.MatchAny)
+	case KindFamilyString:
+		data := kind.Data.(StringKind)
+		writeUint32(buf, interner.indexOf(string(data.Value)))
+		writeBool(buf, data.Negate)
+		binary.Write(buf, compiledByteOrder, uint32(data.Flags))
+	case KindFamilySearch:
+		data := kind.Data.(SearchKind)
+		writeUint32(buf, interner.indexOf(string(data.Value)))
+		binary.Write(buf, compiledByteOrder, int32(data.MaxLen))
+	case KindFamilyDefault, KindFamilyClear:
+		// no payload
+	case KindFamilyFloat:
+		data := kind.Data.(FloatKind)
+		binary.Write(buf, compiledByteOrder, int32(data.ByteWidth))
+		binary.Write(buf, compiledByteOrder, int32(data.Endianness))
+		binary.Write(buf, compiledByteOrder, int32(data.FloatTest))
+		binary.Write(buf, compiledByteOrder, data.Value)
+		binary.Write(buf, compiledByteOrder, data.Epsilon)
+		writeBool(buf, data.MatchAny)
+	case KindFamilyRegex:
+		data := kind.Data.(*RegexKind)
+		writeUint32(buf, interner.indexOf(data.Pattern))
+		binary.Write(buf, compiledByteOrder, uint32(data.Flags))
+		binary.Write(buf, compiledByteOrder, int32(data.MaxLines))
+		binary.Write(buf, compiledByteOrder, int32(data.MaxBytes))
+		writeBool(buf, data.Negate)
+	case KindFamilyDate:
+		data := kind.Data.(DateKind)
+		binary.Write(buf, compiledByteOrder, int32(data.ByteWidth))
+		binary.Write(buf, compiledByteOrder, int32(data.Endianness))
+		writeBool(buf, data.Signed)
+		writeBool(buf, data.IsLocal)
+		writeBool(buf, data.IsWindowsFiletime)
+		binary.Write(buf, compiledByteOrder, int32(data.IntegerTest))
+		binary.Write(buf, compiledByteOrder, data.Value)
+		writeUint32(buf, interner.indexOf(data.Layout))
+	case KindFamilyUse:
+		data := kind.Data.(UseKind)
+		writeUint32(buf, interner.indexOf(data.Page))
+		writeBool(buf, data.SwapEndian)
+	default:
+		return fmt.Errorf("wizardry: cannot marshal unknown KindFamily %d", kind.Family)
+	}
+	return nil
+}
+
+func readKind(r *bufio.Reader, interner *stringInterner) (Kind, error) {
+	var kind Kind
+	var family int32
+	if err := binary.Read(r, compiledByteOrder, &family); err != nil {
+		return kind, err
+	}
+	kind.Family = KindFamily(family)
+
+	switch kind.Family {
+	case KindFamilyInteger:
+		var data IntegerKind
+		byteWidth, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.ByteWidth = int(byteWidth)
+		endianness, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.Endianness = Endianness(endianness)
+		if data.Signed, err = readBool(r); err != nil {
+			return kind, err
+		}
+		if data.DoAnd, err = readBool(r); err != nil {
+			return kind, err
+		}
+		if data.AndValue, err = readUint64(r); err != nil {
+			return kind, err
+		}
+		test, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.IntegerTest = IntegerTest(test)
+		if data.Value, err = readInt64(r); err != nil {
+			return kind, err
+		}
+		if data.MatchAny, err = readBool(r); err != nil {
+			return kind, err
+		}
+		kind.Data = data
+	case KindFamilyString:
+		var data StringKind
+		idx, err := readUint32(r)
+		if err != nil {
+			return kind, err
+		}
+		value, err := interner.at(idx)
+		if err != nil {
+			return kind, err
+		}
+		data.Value = []byte(value)
+		if data.Negate, err = readBool(r); err != nil {
+			return kind, err
+		}
+		flags, err := readUint32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.Flags = stringTestFlags(flags)
+		kind.Data = data
+	case KindFamilySearch:
+		var data SearchKind
+		idx, err := readUint32(r)
+		if err != nil {
+			return kind, err
+		}
+		value, err := interner.at(idx)
+		if err != nil {
+			return kind, err
+		}
+		data.Value = []byte(value)
+		maxLen, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.MaxLen = int(maxLen)
+		kind.Data = data
+	case KindFamilyDefault, KindFamilyClear:
+		// no payload
+	case KindFamilyFloat:
+		var data FloatKind
+		byteWidth, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.ByteWidth = int(byteWidth)
+		endianness, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.Endianness = Endianness(endianness)
+		test, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.FloatTest = FloatTest(test)
+		if data.Value, err = readFloat64(r); err != nil {
+			return kind, err
+		}
+		if data.Epsilon, err = readFloat64(r); err != nil {
+			return kind, err
+		}
+		if data.MatchAny, err = readBool(r); err != nil {
+			return kind, err
+		}
+		kind.Data = data
+	case KindFamilyRegex:
+		var data RegexKind
+		idx, err := readUint32(r)
+		if err != nil {
+			return kind, err
+		}
+		if data.Pattern, err = interner.at(idx); err != nil {
+			return kind, err
+		}
+		flags, err := readUint32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.Flags = regexTestFlags(flags)
+		maxLines, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.MaxLines = int(maxLines)
+		maxBytes, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.MaxBytes = int(maxBytes)
+		if data.Negate, err = readBool(r); err != nil {
+			return kind, err
+		}
+		kind.Data = &data
+	case KindFamilyDate:
+		var data DateKind
+		byteWidth, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.ByteWidth = int(byteWidth)
+		endianness, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.Endianness = Endianness(endianness)
+		if data.Signed, err = readBool(r); err != nil {
+			return kind, err
+		}
+		if data.IsLocal, err = readBool(r); err != nil {
+			return kind, err
+		}
+		if data.IsWindowsFiletime, err = readBool(r); err != nil {
+			return kind, err
+		}
+		test, err := readInt32(r)
+		if err != nil {
+			return kind, err
+		}
+		data.IntegerTest = IntegerTest(test)
+		if data.Value, err = readInt64(r); err != nil {
+			return kind, err
+		}
+		layoutIdx, err := readUint32(r)
+		if err != nil {
+			return kind, err
+		}
+		if data.Layout, err = interner.at(layoutIdx); err != nil {
+			return kind, err
+		}
+		kind.Data = data
+	case KindFamilyUse:
+		var data UseKind
+		idx, err := readUint32(r)
+		if err != nil {
+			return kind, err
+		}
+		if data.Page, err = interner.at(idx); err != nil {
+			return kind, err
+		}
+		if data.SwapEndian, err = readBool(r); err != nil {
+			return kind, err
+		}
+		kind.Data = data
+	default:
+		return kind, fmt.Errorf("wizardry: cannot unmarshal unknown KindFamily %d", kind.Family)
+	}
+	return kind, nil
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	binary.Write(buf, compiledByteOrder, v)
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var v uint32
+	err := binary.Read(r, compiledByteOrder, &v)
+	return v, err
+}
+
+func readInt32(r io.Reader) (int32, error) {
+	var v int32
+	err := binary.Read(r, compiledByteOrder, &v)
+	return v, err
+}
+
+func readInt64(r io.Reader) (int64, error) {
+	var v int64
+	err := binary.Read(r, compiledByteOrder, &v)
+	return v, err
+}
+
+func readUint64(r io.Reader) (uint64, error) {
+	var v uint64
+	err := binary.Read(r, compiledByteOrder, &v)
+	return v, err
+}
+
+func readFloat64(r io.Reader) (float64, error) {
+	var v float64
+	err := binary.Read(r, compiledByteOrder, &v)
+	return v, err
+}
+
+func writeBool(buf *bytes.Buffer, v bool) {
+	if v {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+}
+
+func readBool(r io.Reader) (bool, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return false, err
+	}
+	return b[0] != 0, nil
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeUint32(buf, uint32(len(b)))
+	buf.Write(b)
+}
+
+func readBytes(r io.Reader) ([]byte, error) {
+	length, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,195 @@
+package wizardry
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSpellbookMarshalBinaryRoundTrip(t *testing.T) {
+	original := Spellbook{
+		"": []Rule{
+			{
+				Level:       0,
+				Offset:      Offset{OffsetType: OffsetTypeDirect, Direct: 0},
+				Description: []byte("integer rule"),
+				Kind: Kind{
+					Family: KindFamilyInteger,
+					Data: IntegerKind{
+						ByteWidth:   4,
+						Endianness:  BigEndian,
+						Signed:      true,
+						DoAnd:       true,
+						AndValue:    0xff,
+						IntegerTest: IntegerTestBitAnd,
+						Value:       42,
+					},
+				},
+			},
+			{
+				Level: 1,
+				Offset: Offset{
+					OffsetType: OffsetTypeIndirect,
+					IsRelative: true,
+					Indirect: &IndirectOffset{
+						ByteWidth:             4,
+						Endianness:            LittleEndian,
+						OffsetAddress:         8,
+						OffsetAdjustmentType:  OffsetAdjustmentXor,
+						OffsetAdjustmentValue: 0xffff,
+					},
+				},
+				Description: []byte("string rule"),
+				Kind: Kind{
+					Family: KindFamilyString,
+					Data: StringKind{
+						Value:  []byte("hello"),
+						Negate: true,
+					},
+				},
+			},
+			{
+				Description: []byte("search rule"),
+				Kind: Kind{
+					Family: KindFamilySearch,
+					Data:   SearchKind{Value: []byte("needle"), MaxLen: 64},
+				},
+			},
+			{
+				Description: []byte("default rule"),
+				Kind:        Kind{Family: KindFamilyDefault},
+			},
+			{
+				Description: []byte("clear rule"),
+				Kind:        Kind{Family: KindFamilyClear},
+			},
+			{
+				Description: []byte("float rule"),
+				Kind: Kind{
+					Family: KindFamilyFloat,
+					Data: FloatKind{
+						ByteWidth: 8,
+						FloatTest: FloatTestGreaterThan,
+						Value:     3.14,
+						Epsilon:   0.001,
+					},
+				},
+			},
+			{
+				Description: []byte("regex rule"),
+				Kind: Kind{
+					Family: KindFamilyRegex,
+					Data: &RegexKind{
+						Pattern:  `^#!/usr/bin/env`,
+						MaxLines: 1,
+						MaxBytes: 80,
+					},
+				},
+			},
+			{
+				Description: []byte("date rule"),
+				Kind: Kind{
+					Family: KindFamilyDate,
+					Data: DateKind{
+						ByteWidth:         4,
+						Signed:            false,
+						IsWindowsFiletime: true,
+						IntegerTest:       IntegerTestGreaterThan,
+						Value:             0,
+						Layout:            "2006-01-02",
+					},
+				},
+			},
+			{
+				Description: []byte("use rule"),
+				Kind: Kind{
+					Family: KindFamilyUse,
+					Data:   UseKind{Page: "riff", SwapEndian: true},
+				},
+			},
+		},
+	}
+
+	raw, err := original.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	roundTripped := Spellbook{}
+	if err := roundTripped.UnmarshalBinary(raw); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if !reflect.DeepEqual(original, roundTripped) {
+		t.Fatalf("round-tripped spellbook differs from original:\n got: %#v\nwant: %#v", roundTripped, original)
+	}
+}
+
+func TestSpellbookUnmarshalBinaryRejectsTruncatedInput(t *testing.T) {
+	sb := Spellbook{
+		"": []Rule{
+			{
+				Description: []byte("a rule"),
+				Kind:        Kind{Family: KindFamilyInteger, Data: IntegerKind{ByteWidth: 4, Value: 1}},
+			},
+		},
+	}
+
+	raw, err := sb.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	for _, truncateAt := range []int{len(raw) / 2, len(raw) - 1} {
+		truncated := Spellbook{}
+		if err := truncated.UnmarshalBinary(raw[:truncateAt]); err == nil {
+			t.Fatalf("UnmarshalBinary on input truncated to %d bytes (of %d) should have failed, got nil error", truncateAt, len(raw))
+		}
+	}
+}
+
+func TestSpellbookUnmarshalBinaryRejectsBadMagic(t *testing.T) {
+	sb := Spellbook{}
+	if err := sb.UnmarshalBinary([]byte("not a compiled spellbook")); err == nil {
+		t.Fatal("UnmarshalBinary on input with bad magic should have failed, got nil error")
+	}
+}
+
+func TestSpellbookUnmarshalBinaryRejectsVersionMismatch(t *testing.T) {
+	sb := Spellbook{}
+	raw, err := sb.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	// the version field immediately follows the 4-byte magic header
+	raw[4] = byte(compiledFormatVersion + 1)
+	raw[5] = 0
+
+	mismatched := Spellbook{}
+	if err := mismatched.UnmarshalBinary(raw); err == nil {
+		t.Fatal("UnmarshalBinary on input with a mismatched format version should have failed, got nil error")
+	}
+}
+
+func TestSpellbookMarshalBinaryIsDeterministic(t *testing.T) {
+	sb := Spellbook{
+		"zzz": []Rule{{Description: []byte("z rule"), Kind: Kind{Family: KindFamilyInteger, Data: IntegerKind{ByteWidth: 4, Value: 1}}}},
+		"aaa": []Rule{{Description: []byte("a rule"), Kind: Kind{Family: KindFamilyInteger, Data: IntegerKind{ByteWidth: 4, Value: 2}}}},
+		"mmm": []Rule{{Description: []byte("m rule"), Kind: Kind{Family: KindFamilyInteger, Data: IntegerKind{ByteWidth: 4, Value: 3}}}},
+	}
+
+	first, err := sb.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		again, err := sb.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary: %v", err)
+		}
+		if !bytes.Equal(first, again) {
+			t.Fatalf("MarshalBinary produced different output across repeated calls on the same Spellbook")
+		}
+	}
+}
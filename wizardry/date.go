@@ -0,0 +1,63 @@
+package wizardry
+
+import "time"
+
+// windowsFiletimeEpochOffset is the number of 100ns intervals between the
+// Windows FILETIME epoch (1601-01-01) and the Unix epoch (1970-01-01)
+const windowsFiletimeEpochOffset = 116444736000000000
+
+// DateKind describes how to perform a test on a date/time value, mirroring libmagic's
+// date, ldate, bedate, beldate, qdate, qldate tests (and their "-" UTC variants)
+type DateKind struct {
+	ByteWidth  int
+	Endianness Endianness
+	Signed     bool
+	// IsLocal renders Decode's result in the host's local time zone instead of UTC,
+	// mirroring libmagic's "ldate"/"beldate" variants. Because of that, Decode's output
+	// for an IsLocal DateKind depends on the environment's $TZ and isn't reproducible
+	// across hosts - only the instant decoded is, not the zone it's displayed in.
+	IsLocal           bool
+	IsWindowsFiletime bool
+	IntegerTest       IntegerTest
+	Value             int64
+	// Layout is a Go time layout string (e.g. "2006-01-02 15:04:05") used by Format
+	Layout string
+}
+
+// Decode reads the raw integer out of raw using ByteWidth/Endianness/Signed, then
+// turns it into a time.Time: seconds since the Unix epoch, or - when IsWindowsFiletime
+// is set - 100ns intervals since 1601-01-01, as used by NTFS and OLE timestamps
+func (dk DateKind) Decode(raw []byte) time.Time {
+	bo := dk.Endianness.ByteOrder()
+
+	var value int64
+	if dk.ByteWidth == 8 {
+		// int64(uint64) just reinterprets the bits, so Signed makes no difference here
+		value = int64(bo.Uint64(raw))
+	} else if dk.Signed {
+		value = int64(int32(bo.Uint32(raw)))
+	} else {
+		value = int64(bo.Uint32(raw))
+	}
+
+	var t time.Time
+	if dk.IsWindowsFiletime {
+		unix100ns := value - windowsFiletimeEpochOffset
+		t = time.Unix(unix100ns/1e7, (unix100ns%1e7)*100).UTC()
+	} else {
+		t = time.Unix(value, 0).UTC()
+	}
+
+	if dk.IsLocal {
+		t = t.Local()
+	}
+	return t
+}
+
+// Format renders t using Layout, falling back to time.UnixDate when Layout is empty
+func (dk DateKind) Format(t time.Time) string {
+	if dk.Layout == "" {
+		return t.Format(time.UnixDate)
+	}
+	return t.Format(dk.Layout)
+}
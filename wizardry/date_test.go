@@ -0,0 +1,75 @@
+package wizardry
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestDateKindDecodeQdate8Byte(t *testing.T) {
+	want := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	raw := make([]byte, 8)
+	binary.BigEndian.PutUint64(raw, uint64(want.Unix()))
+
+	dk := DateKind{ByteWidth: 8, Endianness: BigEndian}
+	got := dk.Decode(raw)
+	if !got.Equal(want) {
+		t.Errorf("Decode(qdate) = %v, want %v", got, want)
+	}
+}
+
+func TestDateKindDecodeSignedVsUnsigned32Bit(t *testing.T) {
+	// 0xffffffff: as a signed date this is -1 (1969-12-31 23:59:59 UTC), as an unsigned
+	// date (udate/ubedate) it's 2106-02-07 06:28:15 UTC - the high bit must not sign-extend
+	raw := make([]byte, 4)
+	binary.BigEndian.PutUint32(raw, 0xffffffff)
+
+	signed := DateKind{ByteWidth: 4, Endianness: BigEndian, Signed: true}
+	gotSigned := signed.Decode(raw)
+	wantSigned := time.Unix(-1, 0).UTC()
+	if !gotSigned.Equal(wantSigned) {
+		t.Errorf("Decode(signed) = %v, want %v", gotSigned, wantSigned)
+	}
+
+	unsigned := DateKind{ByteWidth: 4, Endianness: BigEndian, Signed: false}
+	gotUnsigned := unsigned.Decode(raw)
+	wantUnsigned := time.Unix(0xffffffff, 0).UTC()
+	if !gotUnsigned.Equal(wantUnsigned) {
+		t.Errorf("Decode(unsigned) = %v, want %v", gotUnsigned, wantUnsigned)
+	}
+	if gotUnsigned.Year() < 2000 {
+		t.Errorf("Decode(unsigned) = %v, looks sign-extended into the past", gotUnsigned)
+	}
+}
+
+func TestDateKindDecodeWindowsFiletime(t *testing.T) {
+	// a known instant, expressed as both a Unix timestamp and the FILETIME libmagic
+	// would store for the same instant (100ns intervals since 1601-01-01)
+	wantUnix := int64(1000000000) // 2001-09-09 01:46:40 UTC
+	filetime := uint64(wantUnix*1e7 + windowsFiletimeEpochOffset)
+
+	raw := make([]byte, 8)
+	binary.LittleEndian.PutUint64(raw, filetime)
+
+	dk := DateKind{ByteWidth: 8, Endianness: LittleEndian, IsWindowsFiletime: true}
+	got := dk.Decode(raw)
+	want := time.Unix(wantUnix, 0).UTC()
+	if !got.Equal(want) {
+		t.Errorf("Decode(filetime) = %v, want %v", got, want)
+	}
+}
+
+func TestDateKindFormat(t *testing.T) {
+	tm := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	dk := DateKind{Layout: "2006-01-02"}
+	if got, want := dk.Format(tm), "2024-03-01"; got != want {
+		t.Errorf("Format with Layout = %q, want %q", got, want)
+	}
+
+	dkNoLayout := DateKind{}
+	if got := dkNoLayout.Format(tm); got == "" {
+		t.Error("Format with no Layout should fall back to time.UnixDate, got empty string")
+	}
+}
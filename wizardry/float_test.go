@@ -0,0 +1,105 @@
+package wizardry
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func float32Bytes(v float32) []byte {
+	raw := make([]byte, 4)
+	binary.BigEndian.PutUint32(raw, math.Float32bits(v))
+	return raw
+}
+
+func float64Bytes(v float64) []byte {
+	raw := make([]byte, 8)
+	binary.BigEndian.PutUint64(raw, math.Float64bits(v))
+	return raw
+}
+
+func TestFloatKindDecode(t *testing.T) {
+	fk := FloatKind{ByteWidth: 4, Endianness: BigEndian}
+	got, err := fk.Decode(float32Bytes(3.5))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got != 3.5 {
+		t.Errorf("Decode = %v, want 3.5", got)
+	}
+
+	fk64 := FloatKind{ByteWidth: 8, Endianness: BigEndian}
+	got64, err := fk64.Decode(float64Bytes(2.71828))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got64 != 2.71828 {
+		t.Errorf("Decode = %v, want 2.71828", got64)
+	}
+}
+
+func TestFloatKindDecodeShortSliceDoesNotPanic(t *testing.T) {
+	fk := FloatKind{ByteWidth: 8, Endianness: BigEndian}
+	_, err := fk.Decode([]byte{0x01, 0x02})
+	if err == nil {
+		t.Fatal("Decode on a too-short slice should return an error, not panic or succeed")
+	}
+}
+
+func TestFloatKindMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		fk   FloatKind
+		raw  []byte
+		want bool
+	}{
+		{
+			name: "equal within epsilon",
+			fk:   FloatKind{ByteWidth: 4, Endianness: BigEndian, FloatTest: FloatTestEqual, Value: 3.14, Epsilon: 0.01},
+			raw:  float32Bytes(3.141),
+			want: true,
+		},
+		{
+			name: "equal outside epsilon",
+			fk:   FloatKind{ByteWidth: 4, Endianness: BigEndian, FloatTest: FloatTestEqual, Value: 3.14, Epsilon: 0.0001},
+			raw:  float32Bytes(3.2),
+			want: false,
+		},
+		{
+			name: "not equal outside epsilon",
+			fk:   FloatKind{ByteWidth: 4, Endianness: BigEndian, FloatTest: FloatTestNotEqual, Value: 3.14, Epsilon: 0.01},
+			raw:  float32Bytes(9.0),
+			want: true,
+		},
+		{
+			name: "less than",
+			fk:   FloatKind{ByteWidth: 4, Endianness: BigEndian, FloatTest: FloatTestLessThan, Value: 10},
+			raw:  float32Bytes(9.5),
+			want: true,
+		},
+		{
+			name: "greater than",
+			fk:   FloatKind{ByteWidth: 4, Endianness: BigEndian, FloatTest: FloatTestGreaterThan, Value: 10},
+			raw:  float32Bytes(9.5),
+			want: false,
+		},
+		{
+			name: "match any ignores the payload",
+			fk:   FloatKind{ByteWidth: 4, Endianness: BigEndian, MatchAny: true},
+			raw:  float32Bytes(0),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fk.Matches(tt.raw)
+			if err != nil {
+				t.Fatalf("Matches: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
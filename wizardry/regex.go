@@ -0,0 +1,88 @@
+package wizardry
+
+import (
+	"regexp"
+	"sync"
+)
+
+// regexTestFlags holds the bit flags that can be set on a RegexKind
+type regexTestFlags uint8
+
+const (
+	// regexCaseInsensitive matches the pattern regardless of case
+	regexCaseInsensitive regexTestFlags = 1 << iota
+	// regexMultiLine lets ^ and $ match at line boundaries, not just at the start/end of the search window
+	regexMultiLine
+	// regexOffsetStart anchors the search window to the rule's offset instead of scanning to the end of it
+	regexOffsetStart
+)
+
+// RegexKind describes how to match a regular expression pattern, mirroring libmagic's
+// "regex" test. The pattern is compiled lazily, on first use, and the compiled
+// *regexp.Regexp is cached on the RegexKind so repeated matches don't pay to recompile it.
+//
+// Because of that cache, RegexKind carries a sync.Once and must always be handled by
+// pointer - Kind.Data holds a *RegexKind, never a RegexKind value, so the cache survives
+// and copies don't trip go vet's copylocks check.
+type RegexKind struct {
+	Pattern  string
+	Flags    regexTestFlags
+	MaxLines int
+	MaxBytes int
+	Negate   bool
+
+	compileOnce sync.Once
+	compiled    *regexp.Regexp
+	compileErr  error
+}
+
+// Compiled returns the compiled form of Pattern, compiling and caching it on first call
+func (rk *RegexKind) Compiled() (*regexp.Regexp, error) {
+	rk.compileOnce.Do(func() {
+		pattern := rk.Pattern
+		if rk.Flags&regexCaseInsensitive != 0 {
+			pattern = "(?i)" + pattern
+		}
+		if rk.Flags&regexMultiLine != 0 {
+			pattern = "(?m)" + pattern
+		}
+		if rk.Flags&regexOffsetStart != 0 {
+			// \A is the true start of the search window, unaffected by (?m), so this
+			// anchors the match there regardless of the other flags
+			pattern = `\A(?:` + pattern + `)`
+		}
+		rk.compiled, rk.compileErr = regexp.Compile(pattern)
+	})
+	return rk.compiled, rk.compileErr
+}
+
+// searchWindow bounds raw to at most MaxBytes bytes and MaxLines newline-terminated
+// lines, whichever comes first, so a regex can't run away across an entire binary file
+func (rk *RegexKind) searchWindow(raw []byte) []byte {
+	if rk.MaxBytes > 0 && len(raw) > rk.MaxBytes {
+		raw = raw[:rk.MaxBytes]
+	}
+	if rk.MaxLines > 0 {
+		lines := 0
+		for i, b := range raw {
+			if b == '\n' {
+				lines++
+				if lines >= rk.MaxLines {
+					return raw[:i+1]
+				}
+			}
+		}
+	}
+	return raw
+}
+
+// FindSubmatch searches raw for Pattern within the bounds set by MaxLines/MaxBytes,
+// returning the matched groups (index 0 is the whole match) so callers can substitute
+// them into a description template, or nil if Pattern did not match
+func (rk *RegexKind) FindSubmatch(raw []byte) ([][]byte, error) {
+	re, err := rk.Compiled()
+	if err != nil {
+		return nil, err
+	}
+	return re.FindSubmatch(rk.searchWindow(raw)), nil
+}
@@ -0,0 +1,84 @@
+package wizardry
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegexKindFindSubmatch(t *testing.T) {
+	rk := &RegexKind{Pattern: `version (\d+\.\d+)`}
+	groups, err := rk.FindSubmatch([]byte("this is version 3.2 of the tool"))
+	if err != nil {
+		t.Fatalf("FindSubmatch: %v", err)
+	}
+	if groups == nil {
+		t.Fatal("FindSubmatch: expected a match, got none")
+	}
+	if got := string(groups[1]); got != "3.2" {
+		t.Errorf("captured group = %q, want %q", got, "3.2")
+	}
+}
+
+func TestRegexKindCaseInsensitive(t *testing.T) {
+	rk := &RegexKind{Pattern: `hello`, Flags: regexCaseInsensitive}
+	if groups, err := rk.FindSubmatch([]byte("HELLO world")); err != nil || groups == nil {
+		t.Fatalf("FindSubmatch with regexCaseInsensitive: groups=%v err=%v, want a match", groups, err)
+	}
+
+	rkSensitive := &RegexKind{Pattern: `hello`}
+	if groups, err := rkSensitive.FindSubmatch([]byte("HELLO world")); err != nil || groups != nil {
+		t.Fatalf("FindSubmatch without regexCaseInsensitive: groups=%v err=%v, want no match", groups, err)
+	}
+}
+
+func TestRegexKindMultiLine(t *testing.T) {
+	raw := []byte("first line\nsecond line\n")
+
+	rk := &RegexKind{Pattern: `^second`, Flags: regexMultiLine}
+	if groups, err := rk.FindSubmatch(raw); err != nil || groups == nil {
+		t.Fatalf("FindSubmatch with regexMultiLine: groups=%v err=%v, want a match", groups, err)
+	}
+
+	rkSingleLine := &RegexKind{Pattern: `^second`}
+	if groups, err := rkSingleLine.FindSubmatch(raw); err != nil || groups != nil {
+		t.Fatalf("FindSubmatch without regexMultiLine: groups=%v err=%v, want no match", groups, err)
+	}
+}
+
+func TestRegexKindOffsetStart(t *testing.T) {
+	rk := &RegexKind{Pattern: `ELF`, Flags: regexOffsetStart}
+	if groups, err := rk.FindSubmatch([]byte("ELF header")); err != nil || groups == nil {
+		t.Fatalf("FindSubmatch anchored at start: groups=%v err=%v, want a match", groups, err)
+	}
+
+	rkLaterInBuffer := &RegexKind{Pattern: `ELF`, Flags: regexOffsetStart}
+	if groups, err := rkLaterInBuffer.FindSubmatch([]byte("xxELF header")); err != nil || groups != nil {
+		t.Fatalf("FindSubmatch anchored at start on a match that isn't at offset 0: groups=%v err=%v, want no match", groups, err)
+	}
+
+	rkUnanchored := &RegexKind{Pattern: `ELF`}
+	if groups, err := rkUnanchored.FindSubmatch([]byte("xxELF header")); err != nil || groups == nil {
+		t.Fatalf("FindSubmatch without regexOffsetStart: groups=%v err=%v, want a match anywhere in the window", groups, err)
+	}
+}
+
+func TestRegexKindSearchWindowBounds(t *testing.T) {
+	raw := bytes.Repeat([]byte("a"), 100)
+	raw = append(raw, []byte("NEEDLE")...)
+
+	rkBounded := &RegexKind{Pattern: `NEEDLE`, MaxBytes: 50}
+	if groups, err := rkBounded.FindSubmatch(raw); err != nil || groups != nil {
+		t.Fatalf("FindSubmatch past MaxBytes: groups=%v err=%v, want no match", groups, err)
+	}
+
+	rkUnbounded := &RegexKind{Pattern: `NEEDLE`}
+	if groups, err := rkUnbounded.FindSubmatch(raw); err != nil || groups == nil {
+		t.Fatalf("FindSubmatch without MaxBytes: groups=%v err=%v, want a match", groups, err)
+	}
+
+	lined := []byte("line one\nline two\nNEEDLE on line three\n")
+	rkMaxLines := &RegexKind{Pattern: `NEEDLE`, MaxLines: 2}
+	if groups, err := rkMaxLines.FindSubmatch(lined); err != nil || groups != nil {
+		t.Fatalf("FindSubmatch past MaxLines: groups=%v err=%v, want no match", groups, err)
+	}
+}
@@ -0,0 +1,43 @@
+package wizardry
+
+import "errors"
+
+// UseKind describes a jump to another page's rules, evaluated as a subroutine at the
+// current offset. It mirrors libmagic's "use pagename" (and swap-endian "use \^pagename").
+type UseKind struct {
+	Page       string
+	SwapEndian bool
+}
+
+// MaxUseDepth bounds how many "use" jumps may be followed in a row before resolution
+// gives up. It guards against cycles in malformed or adversarial rule sets (page "a"
+// using page "b" using page "a", and so on) sending an interpreter into infinite recursion.
+const MaxUseDepth = 200
+
+// ErrUseDepthExceeded is returned when following a chain of "use" rules exceeds MaxUseDepth
+var ErrUseDepthExceeded = errors.New("wizardry: use recursion exceeded MaxUseDepth")
+
+// ResolveUse follows the chain of "use" rules starting at kind - a page whose first rule
+// is itself a KindFamilyUse is followed transparently, accumulating SwapEndian along the
+// way (two swaps cancel out) - until it reaches a page that isn't just a redirect, and
+// returns that page name and the net endian swap to apply while interpreting it.
+// It returns ErrUseDepthExceeded instead of recursing forever on a cyclic rule set.
+func (sb Spellbook) ResolveUse(kind UseKind) (page string, swapEndian bool, err error) {
+	page = kind.Page
+	swapEndian = kind.SwapEndian
+
+	for depth := 0; ; depth++ {
+		if depth >= MaxUseDepth {
+			return "", false, ErrUseDepthExceeded
+		}
+
+		rules, ok := sb[page]
+		if !ok || len(rules) == 0 || rules[0].Kind.Family != KindFamilyUse {
+			return page, swapEndian, nil
+		}
+
+		next := rules[0].Kind.Data.(UseKind)
+		page = next.Page
+		swapEndian = swapEndian != next.SwapEndian
+	}
+}
@@ -0,0 +1,47 @@
+package wizardry
+
+import "testing"
+
+func TestResolveUseFollowsChain(t *testing.T) {
+	sb := Spellbook{
+		"a": []Rule{{Kind: Kind{Family: KindFamilyUse, Data: UseKind{Page: "b", SwapEndian: true}}}},
+		"b": []Rule{{Kind: Kind{Family: KindFamilyUse, Data: UseKind{Page: "c", SwapEndian: true}}}},
+		"c": []Rule{{Kind: Kind{Family: KindFamilyInteger, Data: IntegerKind{Value: 1}}}},
+	}
+
+	page, swapEndian, err := sb.ResolveUse(UseKind{Page: "a"})
+	if err != nil {
+		t.Fatalf("ResolveUse: %v", err)
+	}
+	if page != "c" {
+		t.Errorf("page = %q, want %q", page, "c")
+	}
+	// two swaps along the chain (a->b, b->c) cancel each other out
+	if swapEndian != false {
+		t.Errorf("swapEndian = %v, want false", swapEndian)
+	}
+}
+
+func TestResolveUseRejectsCycle(t *testing.T) {
+	sb := Spellbook{
+		"a": []Rule{{Kind: Kind{Family: KindFamilyUse, Data: UseKind{Page: "b"}}}},
+		"b": []Rule{{Kind: Kind{Family: KindFamilyUse, Data: UseKind{Page: "a"}}}},
+	}
+
+	_, _, err := sb.ResolveUse(UseKind{Page: "a"})
+	if err != ErrUseDepthExceeded {
+		t.Fatalf("ResolveUse on a cyclic spellbook: got err %v, want ErrUseDepthExceeded", err)
+	}
+}
+
+func TestResolveUseMissingPageIsTerminal(t *testing.T) {
+	sb := Spellbook{}
+
+	page, swapEndian, err := sb.ResolveUse(UseKind{Page: "ghost", SwapEndian: true})
+	if err != nil {
+		t.Fatalf("ResolveUse: %v", err)
+	}
+	if page != "ghost" || !swapEndian {
+		t.Errorf("ResolveUse on missing page = (%q, %v), want (\"ghost\", true)", page, swapEndian)
+	}
+}